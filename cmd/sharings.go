@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cozy/cozy-stack/pkg/instance"
+	"github.com/cozy/cozy-stack/pkg/sharings"
+	_ "github.com/lib/pq"
+	"github.com/spf13/cobra"
+)
+
+var sharingsCmdGroup = &cobra.Command{
+	Use:   "sharings [command]",
+	Short: "Interact with the sharings subsystem",
+	Long:  `cozy-stack sharings allows to interact with the sharings subsystem.`,
+}
+
+var sharingsMigrateDomain string
+var sharingsMigrateFrom string
+var sharingsMigrateTo string
+var sharingsMigrateDSN string
+var sharingsMigrateCheckpoint string
+
+var sharingsMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate sharings between the CouchDB and SQL storage backends",
+	Long: `cozy-stack sharings migrate streams every sharing of an instance
+from the --from backend to the --to backend, resuming from
+--checkpoint (the last successfully migrated sharing ID) if a
+previous run of the migration was interrupted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := instance.Get(sharingsMigrateDomain)
+		if err != nil {
+			return err
+		}
+
+		src, dst, err := openSharingsStores(inst, sharingsMigrateFrom, sharingsMigrateTo, sharingsMigrateDSN)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		dump := src.Dump(ctx)
+
+		skipping := sharingsMigrateCheckpoint != ""
+		for sharing := range dump {
+			if skipping {
+				if sharing.ID() == sharingsMigrateCheckpoint {
+					skipping = false
+				}
+				continue
+			}
+
+			// Load one sharing at a time so the checkpoint is only
+			// printed once it is confirmed written to --to, making
+			// --checkpoint safe to resume from after an interruption.
+			single := make(chan *sharings.Sharing, 1)
+			single <- sharing
+			close(single)
+			if err := dst.Load(ctx, single); err != nil {
+				return fmt.Errorf("sharings migrate: failed to migrate sharing %s: %s", sharing.ID(), err)
+			}
+			fmt.Printf("migrated sharing %s (checkpoint)\n", sharing.ID())
+		}
+
+		return nil
+	},
+}
+
+var sharingsSweepDomain string
+
+var sharingsSweepPublicSharesCmd = &cobra.Command{
+	Use:   "sweep-public-shares",
+	Short: "Remove expired public shares of an instance",
+	Long: `cozy-stack sharings sweep-public-shares deletes every public share
+of the given instance whose expiration date is in the past. It is meant
+to be run periodically from the system cron.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := instance.Get(sharingsSweepDomain)
+		if err != nil {
+			return err
+		}
+		return sharings.SweepExpiredPublicShares(inst)
+	},
+}
+
+func openSharingsStores(inst *instance.Instance, from, to, dsn string) (sharings.Store, sharings.Store, error) {
+	backends := map[string]func() (sharings.Store, error){
+		"couch": func() (sharings.Store, error) {
+			return sharings.NewCouchStore(inst), nil
+		},
+		"sql": func() (sharings.Store, error) {
+			db, err := sql.Open("postgres", dsn)
+			if err != nil {
+				return nil, err
+			}
+			return sharings.NewSQLStore(db), nil
+		},
+	}
+
+	srcFn, ok := backends[from]
+	if !ok {
+		return nil, nil, fmt.Errorf("sharings migrate: unknown --from backend %q", from)
+	}
+	dstFn, ok := backends[to]
+	if !ok {
+		return nil, nil, fmt.Errorf("sharings migrate: unknown --to backend %q", to)
+	}
+
+	src, err := srcFn()
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := dstFn()
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func init() {
+	sharingsMigrateCmd.Flags().StringVar(&sharingsMigrateDomain, "domain", "", "domain of the instance to migrate")
+	sharingsMigrateCmd.Flags().StringVar(&sharingsMigrateFrom, "from", "couch", "source storage backend (couch or sql)")
+	sharingsMigrateCmd.Flags().StringVar(&sharingsMigrateTo, "to", "sql", "destination storage backend (couch or sql)")
+	sharingsMigrateCmd.Flags().StringVar(&sharingsMigrateDSN, "dsn", "", "data source name of the SQL backend")
+	sharingsMigrateCmd.Flags().StringVar(&sharingsMigrateCheckpoint, "checkpoint", "", "ID of the last sharing successfully migrated in a previous run")
+
+	sharingsSweepPublicSharesCmd.Flags().StringVar(&sharingsSweepDomain, "domain", "", "domain of the instance to sweep")
+
+	sharingsCmdGroup.AddCommand(sharingsMigrateCmd)
+	sharingsCmdGroup.AddCommand(sharingsSweepPublicSharesCmd)
+	RootCmd.AddCommand(sharingsCmdGroup)
+}
@@ -0,0 +1,24 @@
+package sharings
+
+import "errors"
+
+var (
+	// ErrMissingRemoteHost is used when an OCM operation is attempted
+	// without a remote host to contact.
+	ErrMissingRemoteHost = errors.New("sharings: missing remote host")
+	// ErrOCMDiscoveryFailed is used when the /ocm-provider discovery
+	// document of a remote host cannot be fetched or is disabled.
+	ErrOCMDiscoveryFailed = errors.New("sharings: OCM discovery failed")
+	// ErrPublicShareNotFound is used when no public share matches the
+	// given token, or it has expired.
+	ErrPublicShareNotFound = errors.New("sharings: public share not found")
+	// ErrInvalidPassword is used when the password given to access a
+	// password-protected public share does not match.
+	ErrInvalidPassword = errors.New("sharings: invalid password")
+	// ErrGroupDoesNotExist is used when a SharingRecipient references a
+	// Group that cannot be found.
+	ErrGroupDoesNotExist = errors.New("sharings: group does not exist")
+	// ErrSharingNotFound is used when no sharing matches the given
+	// SharingID.
+	ErrSharingNotFound = errors.New("sharings: sharing not found")
+)
@@ -0,0 +1,154 @@
+package sharings
+
+import (
+	"github.com/cozy/cozy-stack/pkg/consts"
+	"github.com/cozy/cozy-stack/pkg/couchdb"
+	"github.com/cozy/cozy-stack/web/jsonapi"
+)
+
+// Group is a named collection of recipient IDs that can be targeted as
+// a single entity by a sharing.
+type Group struct {
+	GID  string `json:"_id,omitempty"`
+	GRev string `json:"_rev,omitempty"`
+
+	Name    string   `json:"name"`
+	Members []string `json:"members"` // Recipient IDs
+}
+
+// ID returns the group qualified identifier
+func (g *Group) ID() string { return g.GID }
+
+// Rev returns the group revision
+func (g *Group) Rev() string { return g.GRev }
+
+// DocType returns the group document type
+func (g *Group) DocType() string { return consts.Groups }
+
+// SetID changes the group qualified identifier
+func (g *Group) SetID(id string) { g.GID = id }
+
+// SetRev changes the group revision
+func (g *Group) SetRev(rev string) { g.GRev = rev }
+
+// Links implements jsonapi.Doc
+func (g *Group) Links() *jsonapi.LinksList {
+	return &jsonapi.LinksList{Self: "/sharings/groups/" + g.GID}
+}
+
+// Relationships is part of the jsonapi.Object interface
+func (g *Group) Relationships() jsonapi.RelationshipMap { return nil }
+
+// Included is part of the jsonapi.Object interface
+func (g *Group) Included() []jsonapi.Object { return nil }
+
+// GetGroup returns the Group stored in database from a given ID
+func GetGroup(db couchdb.Database, groupID string) (*Group, error) {
+	doc := &Group{}
+	err := couchdb.GetDoc(db, consts.Groups, groupID, doc)
+	if couchdb.IsNotFoundError(err) {
+		err = ErrGroupDoesNotExist
+	}
+	return doc, err
+}
+
+// MemberState is the per-user delivery state of a sharing that targets
+// a group, keyed by recipient ID.
+type MemberState struct {
+	Status       string `json:"status,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// ExpandGroup turns a group-targeted recipient into one MemberState
+// per group member, creating missing entries with a pending status.
+// It is called from Sharing.Recipients, and so from
+// CheckSharingCreation; members added to a group later are provisioned
+// by AddGroupMember through ProvisionGroupMember.
+func ExpandGroup(db couchdb.Database, sRec *SharingRecipient) error {
+	if sRec.RecipientType != consts.GroupRecipientType {
+		return nil
+	}
+	group, err := GetGroup(db, sRec.RefRecipient.ID)
+	if err != nil {
+		return err
+	}
+
+	if sRec.Members == nil {
+		sRec.Members = make(map[string]*MemberState)
+	}
+	for _, memberID := range group.Members {
+		if _, ok := sRec.Members[memberID]; !ok {
+			sRec.Members[memberID] = &MemberState{Status: consts.PendingSharingStatus}
+		}
+	}
+	return nil
+}
+
+// ProvisionGroupMember retroactively provisions a share entry for a
+// user who joins a group after the sharing was already created, ready
+// for the normal OAuth handshake to run against it.
+func ProvisionGroupMember(sRec *SharingRecipient, memberID string) {
+	if sRec.RecipientType != consts.GroupRecipientType {
+		return
+	}
+	if sRec.Members == nil {
+		sRec.Members = make(map[string]*MemberState)
+	}
+	if _, ok := sRec.Members[memberID]; !ok {
+		sRec.Members[memberID] = &MemberState{Status: consts.PendingSharingStatus}
+	}
+}
+
+// AddGroupMember adds memberID to the group's membership and
+// retroactively provisions it on every sharing that already targets
+// this group, so existing sharings immediately cover the new member.
+func AddGroupMember(db couchdb.Database, groupID, memberID string) (*Group, error) {
+	group, err := GetGroup(db, groupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range group.Members {
+		if m == memberID {
+			return group, nil
+		}
+	}
+	group.Members = append(group.Members, memberID)
+	if err := couchdb.UpdateDoc(db, group); err != nil {
+		return nil, err
+	}
+	if err := provisionGroupMemberInSharings(db, groupID, memberID); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// provisionGroupMemberInSharings provisions memberID on every sharing
+// that targets groupID as a group recipient.
+func provisionGroupMemberInSharings(db couchdb.Database, groupID, memberID string) error {
+	var all []*Sharing
+	req := &couchdb.AllDocsRequest{}
+	if err := couchdb.GetAllDocs(db, consts.Sharings, req, &all); err != nil {
+		return err
+	}
+	for _, sharing := range all {
+		changed := false
+		for _, sRec := range sharing.SRecipients {
+			if sRec.IsGroup() && sRec.RefRecipient.ID == groupID {
+				ProvisionGroupMember(sRec, memberID)
+				changed = true
+			}
+		}
+		if changed {
+			if err := couchdb.UpdateDoc(db, sharing); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	_ couchdb.Doc    = &Group{}
+	_ jsonapi.Object = &Group{}
+)
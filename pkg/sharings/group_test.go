@@ -0,0 +1,55 @@
+package sharings
+
+import (
+	"testing"
+
+	"github.com/cozy/cozy-stack/pkg/consts"
+)
+
+func TestExpandGroupSkipsNonGroupRecipients(t *testing.T) {
+	sRec := &SharingRecipient{RecipientType: consts.UserRecipientType}
+
+	// A non-group recipient must be left untouched without ever
+	// reaching the database.
+	if err := ExpandGroup(nil, sRec); err != nil {
+		t.Fatalf("ExpandGroup() = %v, want nil", err)
+	}
+	if sRec.Members != nil {
+		t.Errorf("Members = %v, want nil", sRec.Members)
+	}
+}
+
+func TestProvisionGroupMember(t *testing.T) {
+	t.Run("non-group recipient is a no-op", func(t *testing.T) {
+		sRec := &SharingRecipient{RecipientType: consts.UserRecipientType}
+		ProvisionGroupMember(sRec, "alice")
+		if sRec.Members != nil {
+			t.Errorf("Members = %v, want nil", sRec.Members)
+		}
+	})
+
+	t.Run("provisions a missing member as pending", func(t *testing.T) {
+		sRec := &SharingRecipient{RecipientType: consts.GroupRecipientType}
+		ProvisionGroupMember(sRec, "alice")
+		member, ok := sRec.Members["alice"]
+		if !ok {
+			t.Fatal("expected a MemberState for alice")
+		}
+		if member.Status != consts.PendingSharingStatus {
+			t.Errorf("Status = %q, want %q", member.Status, consts.PendingSharingStatus)
+		}
+	})
+
+	t.Run("does not overwrite an existing member", func(t *testing.T) {
+		sRec := &SharingRecipient{
+			RecipientType: consts.GroupRecipientType,
+			Members: map[string]*MemberState{
+				"alice": {Status: "active"},
+			},
+		}
+		ProvisionGroupMember(sRec, "alice")
+		if sRec.Members["alice"].Status != "active" {
+			t.Errorf("Status = %q, want %q (should not be reset)", sRec.Members["alice"].Status, "active")
+		}
+	})
+}
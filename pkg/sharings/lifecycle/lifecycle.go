@@ -0,0 +1,167 @@
+// Package lifecycle implements the state machine that governs the
+// status of a sharing recipient (the Status string of
+// sharings.SharingRecipient). The recipient transition endpoints of
+// web/sharings go through it; sharings.ReceiveOCMNotification sets
+// Status directly, without a transition check or a SharingEvent.
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cozy/cozy-stack/pkg/consts"
+	"github.com/cozy/cozy-stack/pkg/couchdb"
+	"github.com/cozy/cozy-stack/pkg/realtime"
+)
+
+// State is one of the states a sharing recipient can be in.
+type State string
+
+// The states of the sharing recipient state machine.
+const (
+	StatePending   State = "pending"
+	StateAccepted  State = "accepted"
+	StateActive    State = "active"
+	StateSuspended State = "suspended"
+	StateRevoked   State = "revoked"
+	StateDeclined  State = "declined"
+	StateExpired   State = "expired"
+)
+
+// transitions lists, for each state, the set of states it can legally
+// move to.
+var transitions = map[State][]State{
+	StatePending:   {StateAccepted, StateDeclined, StateExpired},
+	StateAccepted:  {StateActive, StateRevoked},
+	StateActive:    {StateSuspended, StateRevoked, StateExpired},
+	StateSuspended: {StateActive, StateRevoked},
+}
+
+// ErrIllegalTransition is returned when a transition is not allowed
+// from the recipient's current state.
+type ErrIllegalTransition struct {
+	From State
+	To   State
+}
+
+func (e *ErrIllegalTransition) Error() string {
+	return fmt.Sprintf("lifecycle: cannot transition from %q to %q", e.From, e.To)
+}
+
+// CheckTransition returns an *ErrIllegalTransition if moving from "from"
+// to "to" is not allowed by the state machine.
+func CheckTransition(from, to State) error {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &ErrIllegalTransition{From: from, To: to}
+}
+
+// SharingEvent is an append-only audit record of a state transition on
+// a sharing recipient.
+type SharingEvent struct {
+	EID  string `json:"_id,omitempty"`
+	ERev string `json:"_rev,omitempty"`
+
+	SharingID   string    `json:"sharing_id"`
+	RecipientID string    `json:"recipient_id"`
+	Who         string    `json:"who"`
+	From        State     `json:"from"`
+	To          State     `json:"to"`
+	Reason      string    `json:"reason,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ID returns the event qualified identifier
+func (e *SharingEvent) ID() string { return e.EID }
+
+// Rev returns the event revision
+func (e *SharingEvent) Rev() string { return e.ERev }
+
+// DocType returns the event document type
+func (e *SharingEvent) DocType() string { return consts.SharingEvents }
+
+// SetID changes the event qualified identifier
+func (e *SharingEvent) SetID(id string) { e.EID = id }
+
+// SetRev changes the event revision
+func (e *SharingEvent) SetRev(rev string) { e.ERev = rev }
+
+// WebhookURLs are the URLs that Transition notifies (HTTP POST) of the
+// transition it applies. They are given by the caller of Transition;
+// they are not stored on the sharing.
+type WebhookURLs []string
+
+// Transition validates and applies a state transition on a sharing
+// recipient: it checks the move is legal, records a SharingEvent, and
+// fans the event out to the configured webhooks and to the instance's
+// realtime hub. ctx is propagated to the webhook requests, so that
+// cancellation and tracing reach the webhook endpoints.
+func Transition(ctx context.Context, db couchdb.Database, sharingID, recipientID, who string, from, to State, reason string, webhooks WebhookURLs) error {
+	if err := CheckTransition(from, to); err != nil {
+		return err
+	}
+
+	event := &SharingEvent{
+		SharingID:   sharingID,
+		RecipientID: recipientID,
+		Who:         who,
+		From:        from,
+		To:          to,
+		Reason:      reason,
+		CreatedAt:   time.Now(),
+	}
+	if err := couchdb.CreateDoc(db, event); err != nil {
+		return err
+	}
+
+	realtime.GetHub().Publish(db, realtime.EventUpdate, event, nil)
+	dispatchWebhooks(ctx, webhooks, event)
+
+	return nil
+}
+
+// Events returns the audit trail of a sharing, oldest first.
+func Events(db couchdb.Database, sharingID string) ([]*SharingEvent, error) {
+	var events []*SharingEvent
+	req := &couchdb.ViewRequest{
+		StartKey:    []interface{}{sharingID},
+		EndKey:      []interface{}{sharingID, couchdb.MaxString},
+		IncludeDocs: true,
+	}
+	if err := couchdb.ExecView(db, SharingEventsBySharingIDView, req, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func dispatchWebhooks(ctx context.Context, webhooks WebhookURLs, event *SharingEvent) {
+	for _, url := range webhooks {
+		go func(url string) {
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+			if err != nil {
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return
+			}
+			res.Body.Close()
+		}(url)
+	}
+}
+
+var (
+	_ couchdb.Doc = &SharingEvent{}
+)
@@ -0,0 +1,44 @@
+package lifecycle
+
+import "testing"
+
+func TestCheckTransition(t *testing.T) {
+	cases := []struct {
+		name    string
+		from    State
+		to      State
+		wantErr bool
+	}{
+		{"pending to accepted", StatePending, StateAccepted, false},
+		{"pending to declined", StatePending, StateDeclined, false},
+		{"pending to expired", StatePending, StateExpired, false},
+		{"pending to active", StatePending, StateActive, true},
+		{"accepted to active", StateAccepted, StateActive, false},
+		{"accepted to revoked", StateAccepted, StateRevoked, false},
+		{"accepted to pending", StateAccepted, StatePending, true},
+		{"active to suspended", StateActive, StateSuspended, false},
+		{"active to revoked", StateActive, StateRevoked, false},
+		{"active to expired", StateActive, StateExpired, false},
+		{"suspended to active", StateSuspended, StateActive, false},
+		{"suspended to revoked", StateSuspended, StateRevoked, false},
+		{"suspended to expired", StateSuspended, StateExpired, true},
+		{"revoked to active", StateRevoked, StateActive, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := CheckTransition(c.from, c.to)
+			if c.wantErr {
+				illegal, ok := err.(*ErrIllegalTransition)
+				if !ok {
+					t.Fatalf("CheckTransition(%q, %q) = %v, want *ErrIllegalTransition", c.from, c.to, err)
+				}
+				if illegal.From != c.from || illegal.To != c.to {
+					t.Errorf("ErrIllegalTransition = %+v, want From=%q To=%q", illegal, c.from, c.to)
+				}
+			} else if err != nil {
+				t.Errorf("CheckTransition(%q, %q) = %v, want nil", c.from, c.to, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package lifecycle
+
+import (
+	"github.com/cozy/cozy-stack/pkg/consts"
+	"github.com/cozy/cozy-stack/pkg/couchdb"
+)
+
+// SharingEventsBySharingIDView indexes io.cozy.sharings.events
+// documents by their sharing_id, so Events can return a sharing's
+// audit trail in a single request instead of scanning every event.
+var SharingEventsBySharingIDView = &couchdb.View{
+	Name:    "by-sharing-id",
+	Doctype: consts.SharingEvents,
+	Map: `
+function(doc) {
+  if (doc.sharing_id) {
+    emit([doc.sharing_id, doc.created_at], null);
+  }
+}`,
+}
+
+// Views lists the CouchDB views owned by the lifecycle package.
+var Views = []*couchdb.View{
+	SharingEventsBySharingIDView,
+}
+
+func init() {
+	couchdb.RegisterView(SharingEventsBySharingIDView)
+}
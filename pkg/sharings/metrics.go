@@ -0,0 +1,61 @@
+package sharings
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	sharingCreationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cozy",
+		Subsystem: "sharings",
+		Name:      "creations_total",
+		Help:      "Number of sharings created, by sharing type.",
+	}, []string{"type"})
+
+	recipientAcceptanceLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "cozy",
+		Subsystem: "sharings",
+		Name:      "recipient_acceptance_latency_seconds",
+		Help:      "Time elapsed between a sharing being sent to a recipient and it being accepted.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	})
+
+	ocmHandshakeFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "cozy",
+		Subsystem: "sharings",
+		Name:      "ocm_handshake_failures_total",
+		Help:      "Number of failed OCM discovery/share/notification handshakes with remote servers.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(sharingCreationsTotal, recipientAcceptanceLatency, ocmHandshakeFailuresTotal)
+}
+
+// observeSharingCreation increments the per-type sharing creation
+// counter.
+func observeSharingCreation(sharingType string) {
+	sharingCreationsTotal.WithLabelValues(sharingType).Inc()
+}
+
+// observeRecipientAcceptance records the time elapsed between a
+// sharing being sent and its acceptance by the recipient.
+func observeRecipientAcceptance(sentAt time.Time) {
+	recipientAcceptanceLatency.Observe(time.Since(sentAt).Seconds())
+}
+
+// ObserveRecipientAcceptance records the time elapsed between a
+// sharing being sent and its acceptance by the recipient. It is
+// exported so that web/sharings can report it once a recipient
+// transitions to lifecycle.StateAccepted.
+func ObserveRecipientAcceptance(sentAt time.Time) {
+	observeRecipientAcceptance(sentAt)
+}
+
+// observeOCMHandshakeFailure increments the OCM handshake failure
+// counter.
+func observeOCMHandshakeFailure() {
+	ocmHandshakeFailuresTotal.Inc()
+}
@@ -0,0 +1,377 @@
+package sharings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cozy/cozy-stack/pkg/consts"
+	"github.com/cozy/cozy-stack/pkg/couchdb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ocmProviderPath is the well-known path used to discover the OCM
+// capabilities of a remote server, as described by the OCM 1.1 spec.
+const ocmProviderPath = "/ocm-provider"
+
+// ocmDefaultScheme is prefixed to a bare host (e.g. the one returned by
+// FederatedID.Host) when it carries no scheme of its own. It is a var,
+// not a const, so tests can point discovery at a plain-HTTP test server.
+var ocmDefaultScheme = "https://"
+
+// ocmBaseURL returns host unchanged if it already specifies a scheme,
+// or prefixes it with ocmDefaultScheme otherwise.
+func ocmBaseURL(host string) string {
+	host = strings.TrimSuffix(host, "/")
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		return host
+	}
+	return ocmDefaultScheme + host
+}
+
+// OCMProvider describes the endpoints exposed by a remote server, as
+// returned by its /ocm-provider discovery document.
+type OCMProvider struct {
+	Enabled    bool              `json:"enabled"`
+	APIVersion string            `json:"apiVersion"`
+	Endpoint   string            `json:"endPoint"`
+	Resources  []OCMResourceType `json:"resourceTypes"`
+}
+
+// OCMResourceType describes one resource type (e.g. "file") and the
+// protocols a remote server supports for it.
+type OCMResourceType struct {
+	Name       string            `json:"name"`
+	ShareTypes []string          `json:"shareTypes"`
+	Protocols  map[string]string `json:"protocols"`
+}
+
+// OCMShare is the payload sent to POST /ocm/shares to announce an
+// incoming share to a remote Cozy, Nextcloud, ownCloud or Reva server.
+type OCMShare struct {
+	ShareWith    string      `json:"shareWith"` // federated ID of the recipient, user@host
+	Name         string      `json:"name"`      // display name of the shared resource
+	Description  string      `json:"description,omitempty"`
+	ProviderID   string      `json:"providerId"` // sharing_id on the sending instance
+	Owner        string      `json:"owner"`      // federated ID of the owner
+	Sender       string      `json:"sender"`     // federated ID of the sharer
+	ShareType    string      `json:"shareType"`  // "user" or "group"
+	ResourceType string      `json:"resourceType"`
+	Protocol     OCMProtocol `json:"protocol"`
+}
+
+// OCMProtocol describes how the recipient should reach back to the
+// sender once the share is accepted.
+type OCMProtocol struct {
+	Name         string `json:"name"`
+	WebDAVURL    string `json:"webdavUrl,omitempty"`
+	SharedSecret string `json:"sharedSecret,omitempty"`
+}
+
+// OCMNotification is the payload sent to/received on
+// /ocm/notifications for accept/decline/revoke/update events.
+type OCMNotification struct {
+	NotificationType string                 `json:"notificationType"` // SHARE_ACCEPTED, SHARE_DECLINED, SHARE_UNSHARED, ...
+	ResourceType     string                 `json:"resourceType"`
+	ProviderID       string                 `json:"providerId"`
+	Message          map[string]interface{} `json:"notification,omitempty"`
+}
+
+// FederatedID is a user identifier of the form "user@remote-host".
+type FederatedID string
+
+// Host returns the remote host part of a federated ID: everything after
+// its first "@", or "" if it has none.
+func (f FederatedID) Host() string {
+	parts := strings.SplitN(string(f), "@", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// DiscoverOCMProvider fetches the /ocm-provider well-known document of
+// a remote host to learn how to reach its OCM endpoints.
+func DiscoverOCMProvider(ctx context.Context, host string) (*OCMProvider, error) {
+	_, span := tracer.Start(ctx, "sharings.DiscoverOCMProvider", trace.WithAttributes(attribute.String("ocm.host", host)))
+	defer span.End()
+
+	provider, err := discoverOCMProvider(ctx, host)
+	if err != nil {
+		observeOCMHandshakeFailure()
+		return nil, recordError(span, err)
+	}
+	return provider, nil
+}
+
+func discoverOCMProvider(ctx context.Context, host string) (*OCMProvider, error) {
+	if host == "" {
+		return nil, ErrMissingRemoteHost
+	}
+	url := ocmBaseURL(host) + ocmProviderPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, ErrOCMDiscoveryFailed
+	}
+	provider := &OCMProvider{}
+	if err := json.NewDecoder(res.Body).Decode(provider); err != nil {
+		return nil, err
+	}
+	if !provider.Enabled {
+		return nil, ErrOCMDiscoveryFailed
+	}
+	return provider, nil
+}
+
+// SendOCMShare discovers the recipient's OCM endpoints and posts a
+// share creation payload to announce the sharing. It is called from
+// CheckSharingCreation for recipients that only carry a FederatedID.
+func SendOCMShare(ctx context.Context, db couchdb.Database, sharing *Sharing, recipient FederatedID, localFederatedID, webDAVURL string) error {
+	ctx, span := tracer.Start(ctx, "sharings.SendOCMShare", trace.WithAttributes(
+		append(sharingAttributes(sharing), attribute.String("ocm.recipient_host", recipient.Host()))...,
+	))
+	defer span.End()
+
+	provider, err := DiscoverOCMProvider(ctx, recipient.Host())
+	if err != nil {
+		return err
+	}
+
+	payload := OCMShare{
+		ShareWith:    string(recipient),
+		Name:         sharing.Desc,
+		Description:  sharing.Desc,
+		ProviderID:   sharing.SharingID,
+		Owner:        localFederatedID,
+		Sender:       localFederatedID,
+		ShareType:    "user",
+		ResourceType: "file",
+		Protocol: OCMProtocol{
+			Name:      "webdav",
+			WebDAVURL: webDAVURL,
+		},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return recordError(span, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(provider.Endpoint, "/")+"/shares", bytes.NewReader(body))
+	if err != nil {
+		return recordError(span, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		observeOCMHandshakeFailure()
+		return recordError(span, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		observeOCMHandshakeFailure()
+		return recordError(span, fmt.Errorf("ocm: remote refused the share (status %d)", res.StatusCode))
+	}
+	return nil
+}
+
+// SendOCMNotification notifies the remote sender of a given
+// provider ID that the local user accepted, declined or revoked a
+// share.
+func SendOCMNotification(ctx context.Context, endpoint, notificationType, providerID, resourceType string) error {
+	ctx, span := tracer.Start(ctx, "sharings.SendOCMNotification", trace.WithAttributes(
+		attribute.String("ocm.notification_type", notificationType),
+		attribute.String("sharing.id", providerID),
+	))
+	defer span.End()
+
+	payload := OCMNotification{
+		NotificationType: notificationType,
+		ResourceType:     resourceType,
+		ProviderID:       providerID,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return recordError(span, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(endpoint, "/")+"/notifications", bytes.NewReader(body))
+	if err != nil {
+		return recordError(span, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		observeOCMHandshakeFailure()
+		return recordError(span, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		observeOCMHandshakeFailure()
+		return recordError(span, fmt.Errorf("ocm: remote refused the notification (status %d)", res.StatusCode))
+	}
+	return nil
+}
+
+// OCMInvitation is an exchanged, trusted federated identifier used to
+// bootstrap sharing between two independent stacks before any
+// resource is actually shared. It is the equivalent of
+// FindAcceptedUsers for federated recipients.
+type OCMInvitation struct {
+	IID  string `json:"_id,omitempty"`
+	IRev string `json:"_rev,omitempty"`
+
+	LocalFederatedID  string     `json:"local_federated_id"`
+	RemoteFederatedID string     `json:"remote_federated_id"`
+	State             string     `json:"state"` // pending or accepted
+	AcceptedAt        *time.Time `json:"accepted_at,omitempty"`
+}
+
+// ID returns the invitation qualified identifier
+func (i *OCMInvitation) ID() string { return i.IID }
+
+// Rev returns the invitation revision
+func (i *OCMInvitation) Rev() string { return i.IRev }
+
+// DocType returns the invitation document type
+func (i *OCMInvitation) DocType() string { return consts.OCMInvitations }
+
+// SetID changes the invitation qualified identifier
+func (i *OCMInvitation) SetID(id string) { i.IID = id }
+
+// SetRev changes the invitation revision
+func (i *OCMInvitation) SetRev(rev string) { i.IRev = rev }
+
+// CreateOCMInvitation records that the local instance, identified by
+// localFederatedID, has invited remoteFederatedID to exchange trusted
+// federated identities, in a pending state until the remote accepts.
+func CreateOCMInvitation(ctx context.Context, db couchdb.Database, localFederatedID, remoteFederatedID string) (*OCMInvitation, error) {
+	_, span := tracer.Start(ctx, "sharings.CreateOCMInvitation", trace.WithAttributes(
+		attribute.String("ocm.remote_federated_id", remoteFederatedID),
+	))
+	defer span.End()
+
+	invitation := &OCMInvitation{
+		LocalFederatedID:  localFederatedID,
+		RemoteFederatedID: remoteFederatedID,
+		State:             "pending",
+	}
+	err := couchdb.CreateDoc(db, invitation)
+	return invitation, recordError(span, err)
+}
+
+// AcceptOCMInvitation marks a pending invitation as accepted, so that
+// FindAcceptedOCMUsers will subsequently return it.
+func AcceptOCMInvitation(ctx context.Context, db couchdb.Database, invitationID string) (*OCMInvitation, error) {
+	_, span := tracer.Start(ctx, "sharings.AcceptOCMInvitation", trace.WithAttributes(
+		attribute.String("ocm.invitation_id", invitationID),
+	))
+	defer span.End()
+
+	invitation := &OCMInvitation{}
+	if err := couchdb.GetDoc(db, consts.OCMInvitations, invitationID, invitation); err != nil {
+		return nil, recordError(span, err)
+	}
+
+	now := time.Now()
+	invitation.State = "accepted"
+	invitation.AcceptedAt = &now
+	if err := couchdb.UpdateDoc(db, invitation); err != nil {
+		return nil, recordError(span, err)
+	}
+	return invitation, nil
+}
+
+// ReceiveOCMShare persists an incoming share announced by a remote
+// OCM-speaking server as a pending Sharing owned by the remote.
+func ReceiveOCMShare(ctx context.Context, db couchdb.Database, share *OCMShare) error {
+	ctx, span := tracer.Start(ctx, "sharings.ReceiveOCMShare", trace.WithAttributes(
+		attribute.String("sharing.id", share.ProviderID),
+		attribute.String("ocm.sender", share.Sender),
+	))
+	defer span.End()
+
+	sharing := &Sharing{
+		SharingType: consts.OCMSharing,
+		SharingID:   share.ProviderID,
+		Desc:        share.Description,
+		Owner:       false,
+		SRecipients: []*SharingRecipient{
+			{Status: "pending", FederatedID: FederatedID(share.Sender)},
+		},
+	}
+	err := Create(ctx, db, sharing)
+	return recordError(span, err)
+}
+
+// ReceiveOCMNotification applies an accept/decline/revoke
+// notification received from a remote server to the matching local
+// sharing, identified by its SharingID (the providerId on the remote
+// side).
+func ReceiveOCMNotification(ctx context.Context, db couchdb.Database, notif *OCMNotification) error {
+	_, span := tracer.Start(ctx, "sharings.ReceiveOCMNotification", trace.WithAttributes(
+		attribute.String("ocm.notification_type", notif.NotificationType),
+		attribute.String("sharing.id", notif.ProviderID),
+	))
+	defer span.End()
+
+	if notif.ProviderID == "" {
+		return recordError(span, ErrMissingRemoteHost)
+	}
+	sharing, err := GetSharingBySharingID(db, notif.ProviderID)
+	if err != nil {
+		return recordError(span, err)
+	}
+
+	switch notif.NotificationType {
+	case "SHARE_ACCEPTED":
+		for _, rec := range sharing.SRecipients {
+			if rec.IsOCM() {
+				rec.Status = "accepted"
+			}
+		}
+	case "SHARE_DECLINED", "SHARE_UNSHARED":
+		for _, rec := range sharing.SRecipients {
+			if rec.IsOCM() {
+				rec.Status = "revoked"
+			}
+		}
+	}
+
+	err = couchdb.UpdateDoc(db, sharing)
+	return recordError(span, err)
+}
+
+// FindAcceptedOCMUsers returns the accepted invitations, whose
+// RemoteFederatedID have already gone through the invite/accept
+// handshake with the local instance, so that a new sharing can target
+// them directly.
+func FindAcceptedOCMUsers(db couchdb.Database) ([]*OCMInvitation, error) {
+	var invitations []*OCMInvitation
+	req := &couchdb.AllDocsRequest{}
+	if err := couchdb.GetAllDocs(db, consts.OCMInvitations, req, &invitations); err != nil {
+		return nil, err
+	}
+
+	accepted := invitations[:0]
+	for _, inv := range invitations {
+		if inv.State == "accepted" {
+			accepted = append(accepted, inv)
+		}
+	}
+	return accepted, nil
+}
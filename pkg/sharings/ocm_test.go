@@ -0,0 +1,45 @@
+package sharings
+
+import "testing"
+
+func TestFederatedIDHost(t *testing.T) {
+	cases := []struct {
+		name string
+		id   FederatedID
+		want string
+	}{
+		{"simple", "alice@cozy.example.org", "cozy.example.org"},
+		{"no at sign", "alice", ""},
+		{"empty", "", ""},
+		{"multiple at signs", "alice@bob@cozy.example.org", "bob@cozy.example.org"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.id.Host(); got != c.want {
+				t.Errorf("FederatedID(%q).Host() = %q, want %q", c.id, got, c.want)
+			}
+		})
+	}
+}
+
+func TestOCMBaseURL(t *testing.T) {
+	cases := []struct {
+		name string
+		host string
+		want string
+	}{
+		{"bare host", "cozy.example.org", "https://cozy.example.org"},
+		{"already https", "https://cozy.example.org", "https://cozy.example.org"},
+		{"already http", "http://cozy.example.org", "http://cozy.example.org"},
+		{"trailing slash", "cozy.example.org/", "https://cozy.example.org"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := ocmBaseURL(c.host); got != c.want {
+				t.Errorf("ocmBaseURL(%q) = %q, want %q", c.host, got, c.want)
+			}
+		})
+	}
+}
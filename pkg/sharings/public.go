@@ -0,0 +1,176 @@
+package sharings
+
+import (
+	"time"
+
+	"github.com/cozy/cozy-stack/pkg/consts"
+	"github.com/cozy/cozy-stack/pkg/couchdb"
+	"github.com/cozy/cozy-stack/pkg/permissions"
+	"github.com/cozy/cozy-stack/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PublicShare is a public link share, stored in its own doctype
+// (consts.PublicShares) rather than as a Sharing: a resource made
+// available behind an unguessable token, optionally protected by a
+// password, instead of being shared with a specific recipient.
+type PublicShare struct {
+	SID  string `json:"_id,omitempty"`
+	SRev string `json:"_rev,omitempty"`
+
+	Token          string     `json:"token"`
+	Description    string     `json:"description,omitempty"`
+	PasswordHash   []byte     `json:"password_hash,omitempty"`
+	ExpirationDate *time.Time `json:"expiration_date,omitempty"`
+
+	Permissions *permissions.Set `json:"permissions,omitempty"`
+}
+
+// ID returns the public share qualified identifier
+func (p *PublicShare) ID() string { return p.SID }
+
+// Rev returns the public share revision
+func (p *PublicShare) Rev() string { return p.SRev }
+
+// DocType returns the public share document type
+func (p *PublicShare) DocType() string { return consts.PublicShares }
+
+// SetID changes the public share qualified identifier
+func (p *PublicShare) SetID(id string) { p.SID = id }
+
+// SetRev changes the public share revision
+func (p *PublicShare) SetRev(rev string) { p.SRev = rev }
+
+// HasExpired returns true if the public share has an expiration date
+// that is in the past.
+func (p *PublicShare) HasExpired() bool {
+	return p.ExpirationDate != nil && p.ExpirationDate.Before(time.Now())
+}
+
+// HasPassword returns true if the public share is protected by a
+// password.
+func (p *PublicShare) HasPassword() bool {
+	return len(p.PasswordHash) > 0
+}
+
+// CheckPassword compares the given password against the stored hash.
+func (p *PublicShare) CheckPassword(password string) error {
+	if !p.HasPassword() {
+		return nil
+	}
+	if err := bcrypt.CompareHashAndPassword(p.PasswordHash, []byte(password)); err != nil {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// CreatePublicShare checks fields integrity and creates a PublicShare
+// document that exposes the given scope behind a freshly generated
+// token.
+func CreatePublicShare(db couchdb.Database, description, scope, password string, expirationDate *time.Time) (*PublicShare, error) {
+	if scope == "" {
+		return nil, ErrMissingScope
+	}
+	perms, err := permissions.UnmarshalScopeString(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	share := &PublicShare{
+		Token:          utils.RandomString(32),
+		Description:    description,
+		ExpirationDate: expirationDate,
+		Permissions:    perms,
+	}
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		share.PasswordHash = hash
+	}
+
+	if err := couchdb.CreateDoc(db, share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// GetPublicShareByToken returns the non-expired PublicShare matching
+// the given token, or ErrPublicShareNotFound.
+func GetPublicShareByToken(db couchdb.Database, token string) (*PublicShare, error) {
+	var shares []*PublicShare
+	req := &couchdb.ViewRequest{Key: token, IncludeDocs: true}
+	if err := couchdb.ExecView(db, PublicSharesByTokenView, req, &shares); err != nil {
+		return nil, err
+	}
+	if len(shares) == 0 {
+		return nil, ErrPublicShareNotFound
+	}
+	share := shares[0]
+	if share.HasExpired() {
+		return nil, ErrPublicShareNotFound
+	}
+	return share, nil
+}
+
+// GetPublicShare returns the PublicShare with the given ID, regardless
+// of whether it has expired. Management operations (update, revoke)
+// must still be able to act on an expired share; only
+// GetPublicShareByToken, used to serve the shared resource itself,
+// rejects expired shares.
+func GetPublicShare(db couchdb.Database, id string) (*PublicShare, error) {
+	share := &PublicShare{}
+	err := couchdb.GetDoc(db, consts.PublicShares, id, share)
+	if couchdb.IsNotFoundError(err) {
+		err = ErrPublicShareNotFound
+	}
+	return share, err
+}
+
+// UpdatePublicShare updates the description, expiration date and/or
+// password of an existing public share.
+func UpdatePublicShare(db couchdb.Database, share *PublicShare, description string, expirationDate *time.Time, password *string) error {
+	share.Description = description
+	share.ExpirationDate = expirationDate
+	if password != nil {
+		if *password == "" {
+			share.PasswordHash = nil
+		} else {
+			hash, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			share.PasswordHash = hash
+		}
+	}
+	return couchdb.UpdateDoc(db, share)
+}
+
+// RevokePublicShare deletes a public share, making its token invalid.
+func RevokePublicShare(db couchdb.Database, share *PublicShare) error {
+	return couchdb.DeleteDoc(db, share)
+}
+
+// SweepExpiredPublicShares removes every public share whose expiration
+// date is in the past. It is meant to be called periodically by a
+// cron worker.
+func SweepExpiredPublicShares(db couchdb.Database) error {
+	var shares []*PublicShare
+	req := &couchdb.AllDocsRequest{}
+	if err := couchdb.GetAllDocs(db, consts.PublicShares, req, &shares); err != nil {
+		return err
+	}
+	for _, share := range shares {
+		if share.HasExpired() {
+			if err := couchdb.DeleteDoc(db, share); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	_ couchdb.Doc = &PublicShare{}
+)
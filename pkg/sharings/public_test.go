@@ -0,0 +1,63 @@
+package sharings
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPublicShareHasExpired(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	cases := []struct {
+		name string
+		date *time.Time
+		want bool
+	}{
+		{"no expiration date", nil, false},
+		{"expiration in the past", &past, true},
+		{"expiration in the future", &future, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			share := &PublicShare{ExpirationDate: c.date}
+			if got := share.HasExpired(); got != c.want {
+				t.Errorf("HasExpired() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPublicShareCheckPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		hash     []byte
+		password string
+		wantErr  bool
+	}{
+		{"no password set", nil, "anything", false},
+		{"correct password", hash, "s3cret", false},
+		{"wrong password", hash, "wrong", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			share := &PublicShare{PasswordHash: c.hash}
+			err := share.CheckPassword(c.password)
+			if c.wantErr && err != ErrInvalidPassword {
+				t.Errorf("CheckPassword() = %v, want ErrInvalidPassword", err)
+			}
+			if !c.wantErr && err != nil {
+				t.Errorf("CheckPassword() = %v, want nil", err)
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package sharings
 
 import (
+	"context"
+	"time"
+
 	"github.com/cozy/cozy-stack/pkg/consts"
 	"github.com/cozy/cozy-stack/pkg/couchdb"
 	"github.com/cozy/cozy-stack/pkg/permissions"
 	"github.com/cozy/cozy-stack/pkg/utils"
 	"github.com/cozy/cozy-stack/web/jsonapi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Sharing contains all the information about a sharing
@@ -17,6 +22,10 @@ type Sharing struct {
 	Desc        string `json:"desc,omitempty"`
 	SharingID   string `json:"sharing_id,omitempty"`
 	SharingType string `json:"sharing_type"`
+	// ResourceID is the doctype-qualified identifier of the shared
+	// resource, e.g. "io.cozy.files/12345", used by Store.List to
+	// find every sharing bearing on a given resource.
+	ResourceID string `json:"resource_id,omitempty"`
 
 	Permissions *permissions.Set    `json:"permissions,omitempty"`
 	SRecipients []*SharingRecipient `json:"recipients,omitempty"`
@@ -27,10 +36,39 @@ type SharingRecipient struct {
 	Status       string `json:"status,omitempty"`
 	AccessToken  string `json:"access_token,omitempty"`
 	RefreshToken string `json:"refresh_token,omitempty"`
+	// SentAt is when the sharing was sent to this recipient, i.e. when
+	// Status was last set to consts.PendingSharingStatus. It is used
+	// to measure recipient acceptance latency.
+	SentAt time.Time `json:"sent_at,omitempty"`
+
+	// FederatedID is set instead of RefRecipient when the recipient
+	// lives on a foreign system reached through Open Cloud Mesh, e.g.
+	// "alice@nextcloud.example.org".
+	FederatedID FederatedID `json:"federated_id,omitempty"`
+
+	// RecipientType discriminates whether RefRecipient points at a
+	// single Recipient or a Group. It defaults to consts.UserRecipientType.
+	RecipientType string `json:"recipient_type,omitempty"`
+	// Members holds the per-user delivery state when RecipientType is
+	// consts.GroupRecipientType, keyed by recipient ID.
+	Members map[string]*MemberState `json:"members,omitempty"`
 
 	RefRecipient jsonapi.ResourceIdentifier `json:"recipient,omitempty"`
 
 	recipient *Recipient
+	group     *Group
+}
+
+// IsOCM returns true if the recipient is reached through Open Cloud
+// Mesh federation rather than a local Recipient document.
+func (s *SharingRecipient) IsOCM() bool {
+	return s.FederatedID != ""
+}
+
+// IsGroup returns true if the recipient is a Group rather than a
+// single Recipient.
+func (s *SharingRecipient) IsGroup() bool {
+	return s.RecipientType == consts.GroupRecipientType
 }
 
 // ID returns the sharing qualified identifier
@@ -54,15 +92,31 @@ func (s *Sharing) Links() *jsonapi.LinksList {
 }
 
 // Recipients returns the sharing recipients
-func (s *Sharing) Recipients(db couchdb.Database) ([]*SharingRecipient, error) {
+func (s *Sharing) Recipients(ctx context.Context, db couchdb.Database) ([]*SharingRecipient, error) {
+	ctx, span := tracer.Start(ctx, "sharings.Recipients", trace.WithAttributes(sharingAttributes(s)...))
+	defer span.End()
+
 	var sRecipients []*SharingRecipient
 
 	for _, sRec := range s.SRecipients {
-		recipient, err := GetRecipient(db, sRec.RefRecipient.ID)
-		if err != nil {
-			return nil, err
+		switch {
+		case sRec.IsOCM():
+		case sRec.IsGroup():
+			group, err := GetGroup(db, sRec.RefRecipient.ID)
+			if err != nil {
+				return nil, recordError(span, err)
+			}
+			sRec.group = group
+			if err := ExpandGroup(db, sRec); err != nil {
+				return nil, recordError(span, err)
+			}
+		default:
+			recipient, err := GetRecipient(ctx, db, sRec.RefRecipient.ID)
+			if err != nil {
+				return nil, recordError(span, err)
+			}
+			sRec.recipient = recipient
 		}
-		sRec.recipient = recipient
 		sRecipients = append(sRecipients, sRec)
 	}
 
@@ -73,14 +127,21 @@ func (s *Sharing) Recipients(db couchdb.Database) ([]*SharingRecipient, error) {
 // Relationships is part of the jsonapi.Object interface
 // It is used to generate the recipients relationships
 func (s *Sharing) Relationships() jsonapi.RelationshipMap {
-	l := len(s.SRecipients)
-	i := 0
-
-	data := make([]jsonapi.ResourceIdentifier, l)
+	var data []jsonapi.ResourceIdentifier
 	for _, rec := range s.SRecipients {
-		r := rec.recipient
-		data[i] = jsonapi.ResourceIdentifier{ID: r.ID(), Type: r.DocType()}
-		i++
+		switch {
+		case rec.IsOCM():
+			continue
+		case rec.IsGroup():
+			g := rec.group
+			data = append(data, jsonapi.ResourceIdentifier{ID: g.ID(), Type: g.DocType()})
+			for memberID := range rec.Members {
+				data = append(data, jsonapi.ResourceIdentifier{ID: memberID, Type: consts.Recipients})
+			}
+		default:
+			r := rec.recipient
+			data = append(data, jsonapi.ResourceIdentifier{ID: r.ID(), Type: r.DocType()})
+		}
 	}
 	contents := jsonapi.Relationship{Data: data}
 	return jsonapi.RelationshipMap{"recipients": contents}
@@ -90,26 +151,35 @@ func (s *Sharing) Relationships() jsonapi.RelationshipMap {
 func (s *Sharing) Included() []jsonapi.Object {
 	var included []jsonapi.Object
 	for _, rec := range s.SRecipients {
-		r := rec.recipient
-		included = append(included, r)
+		switch {
+		case rec.IsOCM():
+			continue
+		case rec.IsGroup():
+			included = append(included, rec.group)
+		default:
+			included = append(included, rec.recipient)
+		}
 	}
 	return included
 }
 
 // GetRecipient returns the Recipient stored in database from a given ID
-func GetRecipient(db couchdb.Database, recID string) (*Recipient, error) {
+func GetRecipient(ctx context.Context, db couchdb.Database, recID string) (*Recipient, error) {
+	_, span := tracer.Start(ctx, "sharings.GetRecipient", trace.WithAttributes(attribute.String("recipient.id", recID)))
+	defer span.End()
+
 	doc := &Recipient{}
 	err := couchdb.GetDoc(db, consts.Recipients, recID, doc)
 	if couchdb.IsNotFoundError(err) {
 		err = ErrRecipientDoesNotExist
 	}
-	return doc, err
+	return doc, recordError(span, err)
 }
 
-//CheckSharingType returns an error if the sharing type is incorrect
+// CheckSharingType returns an error if the sharing type is incorrect
 func CheckSharingType(sharingType string) error {
 	switch sharingType {
-	case consts.OneShotSharing, consts.MasterSlaveSharing, consts.MasterMasterSharing:
+	case consts.OneShotSharing, consts.MasterSlaveSharing, consts.MasterMasterSharing, consts.OCMSharing:
 		return nil
 	}
 	return ErrBadSharingType
@@ -117,63 +187,123 @@ func CheckSharingType(sharingType string) error {
 
 // CreateSharingRequest checks fields integrity and creates a sharing document
 // for an incoming sharing request
-func CreateSharingRequest(db couchdb.Database, desc, state, sharingType, scope string) (*Sharing, error) {
+func CreateSharingRequest(ctx context.Context, db couchdb.Database, desc, state, sharingType, scope string) (*Sharing, error) {
+	ctx, span := tracer.Start(ctx, "sharings.CreateSharingRequest", trace.WithAttributes(
+		attribute.String("sharing.type", sharingType),
+	))
+	defer span.End()
+
 	if state == "" {
-		return nil, ErrMissingState
+		return nil, recordError(span, ErrMissingState)
 	}
 	if err := CheckSharingType(sharingType); err != nil {
-		return nil, err
+		return nil, recordError(span, err)
 	}
 	if scope == "" {
-		return nil, ErrMissingScope
+		return nil, recordError(span, ErrMissingScope)
 	}
-	permissions, err := permissions.UnmarshalScopeString(scope)
+	perms, err := permissions.UnmarshalScopeString(scope)
 	if err != nil {
-		return nil, err
+		return nil, recordError(span, err)
 	}
 
 	sharing := &Sharing{
 		SharingType: sharingType,
 		SharingID:   state,
-		Permissions: permissions,
+		Permissions: perms,
 		Owner:       false,
 		Desc:        desc,
 	}
 
-	err = Create(db, sharing)
+	err = Create(ctx, db, sharing)
 
-	return sharing, err
+	return sharing, recordError(span, err)
 }
 
-// CheckSharingCreation initializes and check some sharing fields at creation
-func CheckSharingCreation(db couchdb.Database, sharing *Sharing) error {
+// CheckSharingCreation initializes and check some sharing fields at
+// creation. For recipients reached through Open Cloud Mesh, it also
+// discovers the remote's OCM endpoints and posts a share creation
+// payload, identifying the local instance as localFederatedID and
+// advertising webDAVURL as the protocol to reach back the shared
+// resource.
+func CheckSharingCreation(ctx context.Context, db couchdb.Database, sharing *Sharing, localFederatedID, webDAVURL string) error {
+	ctx, span := tracer.Start(ctx, "sharings.CheckSharingCreation", trace.WithAttributes(sharingAttributes(sharing)...))
+	defer span.End()
 
 	sharingType := sharing.SharingType
 	if err := CheckSharingType(sharingType); err != nil {
-		return err
+		return recordError(span, err)
 	}
 
-	sRecipients, err := sharing.Recipients(db)
+	sRecipients, err := sharing.Recipients(ctx, db)
 	if err != nil {
-		return err
+		return recordError(span, err)
 	}
 	for _, sRec := range sRecipients {
 		sRec.Status = consts.PendingSharingStatus
+		sRec.SentAt = time.Now()
 	}
 
 	sharing.Owner = true
 	sharing.SharingID = utils.RandomString(32)
 
+	for _, sRec := range sRecipients {
+		if !sRec.IsOCM() {
+			continue
+		}
+		if err := SendOCMShare(ctx, db, sharing, sRec.FederatedID, localFederatedID, webDAVURL); err != nil {
+			return recordError(span, err)
+		}
+	}
+
+	observeSharingCreation(sharingType)
+
 	return nil
 }
 
 // Create inserts a Sharing document in database
-func Create(db couchdb.Database, doc *Sharing) error {
+func Create(ctx context.Context, db couchdb.Database, doc *Sharing) error {
+	_, span := tracer.Start(ctx, "sharings.Create", trace.WithAttributes(sharingAttributes(doc)...))
+	defer span.End()
+
 	err := couchdb.CreateDoc(db, doc)
-	return err
+	return recordError(span, err)
+}
+
+// GetSharing returns the Sharing stored in database from a given ID
+func GetSharing(db couchdb.Database, sharingID string) (*Sharing, error) {
+	doc := &Sharing{}
+	err := couchdb.GetDoc(db, consts.Sharings, sharingID, doc)
+	return doc, err
+}
+
+// GetSharingBySharingID returns the Sharing whose SharingID (the
+// exchanged identifier, distinct from the local CouchDB _id) matches
+// sharingID.
+func GetSharingBySharingID(db couchdb.Database, sharingID string) (*Sharing, error) {
+	var sharings []*Sharing
+	req := &couchdb.ViewRequest{Key: sharingID, IncludeDocs: true}
+	if err := couchdb.ExecView(db, SharingsBySharingIDView, req, &sharings); err != nil {
+		return nil, err
+	}
+	if len(sharings) == 0 {
+		return nil, ErrSharingNotFound
+	}
+	return sharings[0], nil
+}
+
+// FindRecipient returns the SharingRecipient of the sharing whose
+// RefRecipient.ID matches the given recipient ID.
+func (s *Sharing) FindRecipient(recipientID string) (*SharingRecipient, error) {
+	for _, sRec := range s.SRecipients {
+		if sRec.RefRecipient.ID == recipientID {
+			return sRec, nil
+		}
+	}
+	return nil, ErrRecipientDoesNotExist
 }
 
 var (
 	_ couchdb.Doc    = &Sharing{}
 	_ jsonapi.Object = &Sharing{}
-)
\ No newline at end of file
+)
@@ -0,0 +1,34 @@
+package sharings
+
+import "context"
+
+// ListFilters narrows down the result of Store.List. A nil/empty field
+// leaves the corresponding dimension unfiltered.
+type ListFilters struct {
+	// Owner, when set, restricts the listing to sharings where the
+	// local instance is (true) or is not (false) the owner.
+	Owner       *bool
+	RecipientID string
+	ResourceID  string
+	State       string
+}
+
+// Store abstracts the persistence of sharings so that it can be
+// backed by CouchDB (the historical backend) or a SQL database, the
+// latter filtering listings by owner and by resource in the query
+// itself (see sqlStore).
+type Store interface {
+	Create(ctx context.Context, sharing *Sharing) error
+	Get(ctx context.Context, sharingID string) (*Sharing, error)
+	List(ctx context.Context, filters ListFilters) ([]*Sharing, error)
+	Update(ctx context.Context, sharing *Sharing) error
+	Delete(ctx context.Context, sharingID string) error
+
+	// Dump streams every sharing known to the store, for migration to
+	// another Store implementation. The channel is closed once every
+	// sharing has been sent, or as soon as an error occurs.
+	Dump(ctx context.Context) <-chan *Sharing
+	// Load consumes sharings produced by another Store's Dump and
+	// persists them, preserving their IDs.
+	Load(ctx context.Context, sharings <-chan *Sharing) error
+}
@@ -0,0 +1,118 @@
+package sharings
+
+import (
+	"context"
+
+	"github.com/cozy/cozy-stack/pkg/consts"
+	"github.com/cozy/cozy-stack/pkg/couchdb"
+	"github.com/cozy/cozy-stack/pkg/logger"
+)
+
+// couchStore is the historical Store implementation, backed by the
+// io.cozy.sharings CouchDB doctype.
+type couchStore struct {
+	db couchdb.Database
+}
+
+// NewCouchStore returns a Store that persists sharings as CouchDB
+// documents in db.
+func NewCouchStore(db couchdb.Database) Store {
+	return &couchStore{db: db}
+}
+
+func (s *couchStore) Create(ctx context.Context, sharing *Sharing) error {
+	return couchdb.CreateDoc(s.db, sharing)
+}
+
+func (s *couchStore) Get(ctx context.Context, sharingID string) (*Sharing, error) {
+	return GetSharing(s.db, sharingID)
+}
+
+func (s *couchStore) List(ctx context.Context, filters ListFilters) ([]*Sharing, error) {
+	var all []*Sharing
+	req := &couchdb.AllDocsRequest{}
+	if err := couchdb.GetAllDocs(s.db, consts.Sharings, req, &all); err != nil {
+		return nil, err
+	}
+
+	var filtered []*Sharing
+	for _, sharing := range all {
+		if filters.Owner != nil && sharing.Owner != *filters.Owner {
+			continue
+		}
+		if filters.ResourceID != "" && sharing.ResourceID != filters.ResourceID {
+			continue
+		}
+		if filters.State != "" && !sharing.hasRecipientInState(filters.State) {
+			continue
+		}
+		if filters.RecipientID != "" && !sharing.hasRecipient(filters.RecipientID) {
+			continue
+		}
+		filtered = append(filtered, sharing)
+	}
+	return filtered, nil
+}
+
+func (s *couchStore) Update(ctx context.Context, sharing *Sharing) error {
+	return couchdb.UpdateDoc(s.db, sharing)
+}
+
+func (s *couchStore) Delete(ctx context.Context, sharingID string) error {
+	sharing, err := GetSharing(s.db, sharingID)
+	if err != nil {
+		return err
+	}
+	return couchdb.DeleteDoc(s.db, sharing)
+}
+
+func (s *couchStore) Dump(ctx context.Context) <-chan *Sharing {
+	out := make(chan *Sharing)
+	go func() {
+		defer close(out)
+		var all []*Sharing
+		req := &couchdb.AllDocsRequest{}
+		if err := couchdb.GetAllDocs(s.db, consts.Sharings, req, &all); err != nil {
+			logger.WithNamespace("sharings").Errorf("dump failed: %s", err)
+			return
+		}
+		for _, sharing := range all {
+			select {
+			case out <- sharing:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (s *couchStore) Load(ctx context.Context, sharings <-chan *Sharing) error {
+	for sharing := range sharings {
+		sharing.SRev = ""
+		if err := couchdb.CreateNamedDoc(s.db, sharing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sharing) hasRecipientInState(state string) bool {
+	for _, rec := range s.SRecipients {
+		if rec.Status == state {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sharing) hasRecipient(recipientID string) bool {
+	for _, rec := range s.SRecipients {
+		if rec.RefRecipient.ID == recipientID {
+			return true
+		}
+	}
+	return false
+}
+
+var _ Store = &couchStore{}
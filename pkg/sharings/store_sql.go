@@ -0,0 +1,172 @@
+package sharings
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+)
+
+// sqlStore is a Store implementation backed by a single SQL table,
+// sharings, used when operators need filtered listings that CouchDB
+// views make painful. Recipients and permissions are stored as JSON
+// columns of that table, not in tables of their own, so List filters
+// by owner and by resource in the query, and by state and by recipient
+// in Go once the rows are read. Its queries use Postgres-style $N
+// placeholders, so db must be a *sql.DB opened with the "postgres"
+// driver; a MySQL-backed store would need its own implementation
+// using "?" placeholders.
+type sqlStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a Store that persists sharings in the given
+// Postgres database. The sharings table must already exist; NewSQLStore
+// does not create it.
+func NewSQLStore(db *sql.DB) Store {
+	return &sqlStore{db: db}
+}
+
+func (s *sqlStore) Create(ctx context.Context, sharing *Sharing) error {
+	recipients, err := json.Marshal(sharing.SRecipients)
+	if err != nil {
+		return err
+	}
+	perms, err := json.Marshal(sharing.Permissions)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO sharings (id, owner, sharing_id, sharing_type, description, resource_id, recipients, permissions)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		sharing.SID, sharing.Owner, sharing.SharingID, sharing.SharingType, sharing.Desc, sharing.ResourceID, recipients, perms)
+	return err
+}
+
+func (s *sqlStore) Get(ctx context.Context, sharingID string) (*Sharing, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, owner, sharing_id, sharing_type, description, resource_id, recipients, permissions
+		FROM sharings WHERE id = $1`, sharingID)
+	return scanSharing(row)
+}
+
+func (s *sqlStore) List(ctx context.Context, filters ListFilters) ([]*Sharing, error) {
+	query := `SELECT id, owner, sharing_id, sharing_type, description, resource_id, recipients, permissions FROM sharings WHERE true`
+	var args []interface{}
+	i := 1
+	if filters.Owner != nil {
+		query += argClause(&i, " AND owner = ")
+		args = append(args, *filters.Owner)
+	}
+	if filters.ResourceID != "" {
+		query += argClause(&i, " AND resource_id = ")
+		args = append(args, filters.ResourceID)
+	}
+	query += " ORDER BY id"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sharings []*Sharing
+	for rows.Next() {
+		sharing, err := scanSharing(rows)
+		if err != nil {
+			return nil, err
+		}
+		if filters.State != "" && !sharing.hasRecipientInState(filters.State) {
+			continue
+		}
+		if filters.RecipientID != "" && !sharing.hasRecipient(filters.RecipientID) {
+			continue
+		}
+		sharings = append(sharings, sharing)
+	}
+	return sharings, rows.Err()
+}
+
+func (s *sqlStore) Update(ctx context.Context, sharing *Sharing) error {
+	recipients, err := json.Marshal(sharing.SRecipients)
+	if err != nil {
+		return err
+	}
+	perms, err := json.Marshal(sharing.Permissions)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE sharings SET owner = $2, sharing_id = $3, sharing_type = $4,
+			description = $5, resource_id = $6, recipients = $7, permissions = $8
+		WHERE id = $1`,
+		sharing.SID, sharing.Owner, sharing.SharingID, sharing.SharingType, sharing.Desc, sharing.ResourceID, recipients, perms)
+	return err
+}
+
+func (s *sqlStore) Delete(ctx context.Context, sharingID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sharings WHERE id = $1`, sharingID)
+	return err
+}
+
+func (s *sqlStore) Dump(ctx context.Context) <-chan *Sharing {
+	out := make(chan *Sharing)
+	go func() {
+		defer close(out)
+		sharings, err := s.List(ctx, ListFilters{})
+		if err != nil {
+			return
+		}
+		for _, sharing := range sharings {
+			select {
+			case out <- sharing:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (s *sqlStore) Load(ctx context.Context, sharings <-chan *Sharing) error {
+	for sharing := range sharings {
+		if err := s.Create(ctx, sharing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSharing(row rowScanner) (*Sharing, error) {
+	var sharing Sharing
+	var recipients, perms []byte
+	err := row.Scan(&sharing.SID, &sharing.Owner, &sharing.SharingID, &sharing.SharingType,
+		&sharing.Desc, &sharing.ResourceID, &recipients, &perms)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) > 0 {
+		if err := json.Unmarshal(recipients, &sharing.SRecipients); err != nil {
+			return nil, err
+		}
+	}
+	if len(perms) > 0 {
+		if err := json.Unmarshal(perms, &sharing.Permissions); err != nil {
+			return nil, err
+		}
+	}
+	return &sharing, nil
+}
+
+func argClause(i *int, clause string) string {
+	s := clause + "$" + strconv.Itoa(*i)
+	*i++
+	return s
+}
+
+var _ Store = &sqlStore{}
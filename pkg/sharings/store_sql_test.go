@@ -0,0 +1,69 @@
+package sharings
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cozy/cozy-stack/web/jsonapi"
+)
+
+// fakeRow is a rowScanner that copies fixed values into Scan's
+// destinations, so scanSharing can be exercised without a real
+// database connection.
+type fakeRow struct {
+	values []interface{}
+}
+
+func (r *fakeRow) Scan(dest ...interface{}) error {
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *string:
+			*d = r.values[i].(string)
+		case *bool:
+			*d = r.values[i].(bool)
+		case *[]byte:
+			*d = r.values[i].([]byte)
+		}
+	}
+	return nil
+}
+
+func TestScanSharingRoundTrip(t *testing.T) {
+	recipients, err := json.Marshal([]*SharingRecipient{
+		{Status: "accepted", RefRecipient: jsonapi.ResourceIdentifier{ID: "bob"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := &fakeRow{values: []interface{}{
+		"sharing-id", true, "sharing-state", "one-shot", "a description", "io.cozy.files/123", recipients, []byte("null"),
+	}}
+
+	sharing, err := scanSharing(row)
+	if err != nil {
+		t.Fatalf("scanSharing() = %v, want nil", err)
+	}
+
+	if sharing.SID != "sharing-id" {
+		t.Errorf("SID = %q, want %q", sharing.SID, "sharing-id")
+	}
+	if !sharing.Owner {
+		t.Error("Owner = false, want true")
+	}
+	if sharing.SharingID != "sharing-state" {
+		t.Errorf("SharingID = %q, want %q", sharing.SharingID, "sharing-state")
+	}
+	if sharing.SharingType != "one-shot" {
+		t.Errorf("SharingType = %q, want %q", sharing.SharingType, "one-shot")
+	}
+	if sharing.Desc != "a description" {
+		t.Errorf("Desc = %q, want %q", sharing.Desc, "a description")
+	}
+	if sharing.ResourceID != "io.cozy.files/123" {
+		t.Errorf("ResourceID = %q, want %q", sharing.ResourceID, "io.cozy.files/123")
+	}
+	if len(sharing.SRecipients) != 1 || sharing.SRecipients[0].Status != "accepted" {
+		t.Errorf("SRecipients = %+v, want one accepted recipient", sharing.SRecipients)
+	}
+}
@@ -0,0 +1,55 @@
+package sharings
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans emitted by this package in the
+// OpenTelemetry backend.
+const tracerName = "github.com/cozy/cozy-stack/pkg/sharings"
+
+var tracer = otel.Tracer(tracerName)
+
+// sharingAttributes builds the common span attributes carried by the
+// sharings spans that bear on a whole Sharing.
+func sharingAttributes(sharing *Sharing) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.Bool("sharing.owner", sharing.Owner),
+	}
+	if sharing.SID != "" {
+		attrs = append(attrs, attribute.String("sharing.id", sharing.SID))
+	}
+	if sharing.SharingType != "" {
+		attrs = append(attrs, attribute.String("sharing.type", sharing.SharingType))
+	}
+	attrs = append(attrs, attribute.Int("sharing.recipient_count", len(sharing.SRecipients)))
+	if sharing.Permissions != nil {
+		attrs = append(attrs, attribute.String("sharing.scope_digest", scopeDigest(fmt.Sprintf("%v", sharing.Permissions))))
+	}
+	return attrs
+}
+
+// scopeDigest returns a short, non-reversible digest of a permission
+// scope string, so spans can carry it without leaking the scope
+// itself.
+func scopeDigest(scope string) string {
+	sum := sha256.Sum256([]byte(scope))
+	return hex.EncodeToString(sum[:8])
+}
+
+// recordError marks the given span as failed and attaches err, then
+// returns err unchanged so it can be used in a single return statement.
+func recordError(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
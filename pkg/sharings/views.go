@@ -0,0 +1,48 @@
+package sharings
+
+import (
+	"github.com/cozy/cozy-stack/pkg/consts"
+	"github.com/cozy/cozy-stack/pkg/couchdb"
+)
+
+// PublicSharesByTokenView indexes io.cozy.public.shares documents by
+// their token, so GetPublicShareByToken can resolve a token in a
+// single request instead of scanning every public share.
+var PublicSharesByTokenView = &couchdb.View{
+	Name:    "by-token",
+	Doctype: consts.PublicShares,
+	Map: `
+function(doc) {
+  if (doc.token) {
+    emit(doc.token, doc._id);
+  }
+}`,
+}
+
+// SharingsBySharingIDView indexes io.cozy.sharings documents by their
+// sharing_id, so GetSharingBySharingID can resolve the shared
+// identifier exchanged with a remote OCM server (which never matches
+// the local CouchDB _id) in a single request.
+var SharingsBySharingIDView = &couchdb.View{
+	Name:    "by-sharing-id",
+	Doctype: consts.Sharings,
+	Map: `
+function(doc) {
+  if (doc.sharing_id) {
+    emit(doc.sharing_id, doc._id);
+  }
+}`,
+}
+
+// Views lists the CouchDB views owned by the sharings package, so that
+// instance creation/migration can declare them alongside every other
+// subsystem's views.
+var Views = []*couchdb.View{
+	PublicSharesByTokenView,
+	SharingsBySharingIDView,
+}
+
+func init() {
+	couchdb.RegisterView(PublicSharesByTokenView)
+	couchdb.RegisterView(SharingsBySharingIDView)
+}
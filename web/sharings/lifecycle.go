@@ -0,0 +1,97 @@
+package sharings
+
+import (
+	"net/http"
+
+	"github.com/cozy/cozy-stack/pkg/couchdb"
+	"github.com/cozy/cozy-stack/pkg/sharings"
+	"github.com/cozy/cozy-stack/pkg/sharings/lifecycle"
+	"github.com/cozy/cozy-stack/web/jsonapi"
+	"github.com/cozy/cozy-stack/web/middlewares"
+	"github.com/labstack/echo"
+)
+
+// actionTargetStates maps each lifecycle HTTP action to the state it
+// moves a recipient into.
+var actionTargetStates = map[string]lifecycle.State{
+	"accept":  lifecycle.StateAccepted,
+	"decline": lifecycle.StateDeclined,
+	"revoke":  lifecycle.StateRevoked,
+	"suspend": lifecycle.StateSuspended,
+	"resume":  lifecycle.StateActive,
+}
+
+// postRecipientTransition handles
+// POST /sharings/:id/recipients/:rid/{accept,decline,revoke,suspend,resume}
+func postRecipientTransition(action string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		instance := middlewares.GetInstance(c)
+		sharingID := c.Param("id")
+		recipientID := c.Param("rid")
+
+		to, ok := actionTargetStates[action]
+		if !ok {
+			return jsonapi.NewError(http.StatusNotFound, echo.ErrNotFound)
+		}
+
+		sharing, err := sharings.GetSharing(instance, sharingID)
+		if err != nil {
+			return jsonapi.NotFound(err)
+		}
+		sRec, err := sharing.FindRecipient(recipientID)
+		if err != nil {
+			return jsonapi.NotFound(err)
+		}
+
+		from := lifecycle.State(sRec.Status)
+		reason := c.QueryParam("reason")
+
+		if err := lifecycle.Transition(c.Request().Context(), instance, sharingID, recipientID, instance.Domain, from, to, reason, nil); err != nil {
+			return jsonapi.PreconditionFailed("state", err)
+		}
+
+		if to == lifecycle.StateAccepted {
+			sharings.ObserveRecipientAcceptance(sRec.SentAt)
+		}
+
+		sRec.Status = string(to)
+		if err := couchdb.UpdateDoc(instance, sharing); err != nil {
+			return jsonapi.InternalServerError(err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// getSharingEvents handles GET /sharings/:id/events
+func getSharingEvents(c echo.Context) error {
+	instance := middlewares.GetInstance(c)
+	events, err := lifecycle.Events(instance, c.Param("id"))
+	if err != nil {
+		return jsonapi.InternalServerError(err)
+	}
+
+	objs := make([]jsonapi.Object, len(events))
+	for i, e := range events {
+		objs[i] = &apiSharingEvent{e}
+	}
+	return jsonapi.DataList(c, http.StatusOK, objs, nil)
+}
+
+type apiSharingEvent struct {
+	*lifecycle.SharingEvent
+}
+
+func (e *apiSharingEvent) Relationships() jsonapi.RelationshipMap { return nil }
+func (e *apiSharingEvent) Included() []jsonapi.Object             { return nil }
+func (e *apiSharingEvent) Links() *jsonapi.LinksList {
+	return &jsonapi.LinksList{Self: "/sharings/" + e.SharingID + "/events/" + e.EID}
+}
+
+// lifecycleRoutes registers the recipient transition and event audit
+// HTTP routes on the given router.
+func lifecycleRoutes(router *echo.Group) {
+	for _, action := range []string{"accept", "decline", "revoke", "suspend", "resume"} {
+		router.POST("/:id/recipients/:rid/"+action, postRecipientTransition(action))
+	}
+	router.GET("/:id/events", getSharingEvents)
+}
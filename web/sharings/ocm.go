@@ -0,0 +1,114 @@
+// Package sharings exposes the HTTP API of the sharings subsystem.
+package sharings
+
+import (
+	"net/http"
+
+	"github.com/cozy/cozy-stack/pkg/sharings"
+	"github.com/cozy/cozy-stack/web/jsonapi"
+	"github.com/cozy/cozy-stack/web/middlewares"
+	"github.com/labstack/echo"
+)
+
+// postOCMShare handles POST /sharings/ocm/shares: a remote OCM-speaking server
+// announces an incoming share for a local user.
+func postOCMShare(c echo.Context) error {
+	instance := middlewares.GetInstance(c)
+
+	var share sharings.OCMShare
+	if err := c.Bind(&share); err != nil {
+		return jsonapi.NewError(http.StatusBadRequest, err)
+	}
+	if share.ShareWith == "" || share.ProviderID == "" {
+		return jsonapi.BadRequest(sharings.ErrMissingRemoteHost)
+	}
+
+	if err := sharings.ReceiveOCMShare(c.Request().Context(), instance, &share); err != nil {
+		return wrapOCMError(err)
+	}
+	return c.JSON(http.StatusCreated, echo.Map{"recipientDisplayName": instance.Domain})
+}
+
+// postOCMNotification handles POST /sharings/ocm/notifications: a remote server
+// reports that a share it received was accepted, declined or revoked.
+func postOCMNotification(c echo.Context) error {
+	instance := middlewares.GetInstance(c)
+
+	var notif sharings.OCMNotification
+	if err := c.Bind(&notif); err != nil {
+		return jsonapi.NewError(http.StatusBadRequest, err)
+	}
+
+	if err := sharings.ReceiveOCMNotification(c.Request().Context(), instance, &notif); err != nil {
+		return wrapOCMError(err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// postOCMInvitation handles POST /sharings/ocm/invitations: the local
+// user invites a remote federated ID to exchange trusted identities,
+// ahead of any resource actually being shared.
+func postOCMInvitation(c echo.Context) error {
+	instance := middlewares.GetInstance(c)
+
+	var params struct {
+		RemoteFederatedID string `json:"remote_federated_id"`
+	}
+	if err := c.Bind(&params); err != nil {
+		return jsonapi.NewError(http.StatusBadRequest, err)
+	}
+	if params.RemoteFederatedID == "" {
+		return jsonapi.BadRequest(sharings.ErrMissingRemoteHost)
+	}
+
+	invitation, err := sharings.CreateOCMInvitation(c.Request().Context(), instance, instance.Domain, params.RemoteFederatedID)
+	if err != nil {
+		return wrapOCMError(err)
+	}
+	return jsonapi.Data(c, http.StatusCreated, &apiOCMInvitation{invitation}, nil)
+}
+
+// postOCMInvitationAccept handles POST /sharings/ocm/invitations/:id/accept: a
+// remote server confirms that its user accepted a pending invitation.
+func postOCMInvitationAccept(c echo.Context) error {
+	instance := middlewares.GetInstance(c)
+
+	invitation, err := sharings.AcceptOCMInvitation(c.Request().Context(), instance, c.Param("id"))
+	if err != nil {
+		return wrapOCMError(err)
+	}
+	return jsonapi.Data(c, http.StatusOK, &apiOCMInvitation{invitation}, nil)
+}
+
+type apiOCMInvitation struct {
+	*sharings.OCMInvitation
+}
+
+func (i *apiOCMInvitation) Relationships() jsonapi.RelationshipMap { return nil }
+func (i *apiOCMInvitation) Included() []jsonapi.Object             { return nil }
+func (i *apiOCMInvitation) Links() *jsonapi.LinksList {
+	return &jsonapi.LinksList{Self: "/sharings/ocm/invitations/" + i.ID()}
+}
+
+func wrapOCMError(err error) error {
+	switch err {
+	case sharings.ErrMissingRemoteHost, sharings.ErrOCMDiscoveryFailed:
+		return jsonapi.BadRequest(err)
+	}
+	return jsonapi.InternalServerError(err)
+}
+
+// ocmRoutes registers the OCM HTTP routes on the given router. Routes
+// mounts it on the /sharings/ocm group, so these endpoints live under
+// /sharings/ocm, not at the root /ocm path.
+func ocmRoutes(router *echo.Group) {
+	router.POST("/shares", postOCMShare)
+	router.POST("/notifications", postOCMNotification)
+	router.POST("/invitations/:id/accept", postOCMInvitationAccept)
+}
+
+// ocmInvitationRoutes registers the authenticated /sharings/ocm/invitations
+// route used by the local user to start an OCM handshake.
+func ocmInvitationRoutes(router *echo.Group) {
+	router.POST("/ocm/invitations", postOCMInvitation)
+}
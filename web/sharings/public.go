@@ -0,0 +1,151 @@
+package sharings
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cozy/cozy-stack/pkg/sharings"
+	"github.com/cozy/cozy-stack/web/jsonapi"
+	"github.com/cozy/cozy-stack/web/middlewares"
+	"github.com/labstack/echo"
+)
+
+type publicShareParams struct {
+	Description    string     `json:"description"`
+	Scope          string     `json:"scope"`
+	Password       string     `json:"password"`
+	ExpirationDate *time.Time `json:"expiration_date"`
+}
+
+// postPublicShare handles POST /sharings/public
+func postPublicShare(c echo.Context) error {
+	instance := middlewares.GetInstance(c)
+
+	var params publicShareParams
+	if err := c.Bind(&params); err != nil {
+		return jsonapi.NewError(http.StatusBadRequest, err)
+	}
+
+	share, err := sharings.CreatePublicShare(instance, params.Description, params.Scope, params.Password, params.ExpirationDate)
+	if err != nil {
+		return wrapPublicShareError(err)
+	}
+	return jsonapi.Data(c, http.StatusCreated, &apiPublicShare{share}, nil)
+}
+
+// getPublicShare handles GET /public/:token, returning 404 once the
+// share has expired and a password challenge when one is set.
+func getPublicShare(c echo.Context) error {
+	instance := middlewares.GetInstance(c)
+	token := c.Param("token")
+
+	share, err := sharings.GetPublicShareByToken(instance, token)
+	if err != nil {
+		return jsonapi.NotFound(err)
+	}
+
+	if share.HasPassword() {
+		auth := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+		if err := share.CheckPassword(auth); err != nil {
+			return jsonapi.NewError(http.StatusUnauthorized, err)
+		}
+	}
+
+	return jsonapi.Data(c, http.StatusOK, &apiPublicShare{share}, nil)
+}
+
+// patchPublicShare handles PATCH /sharings/public/:id
+func patchPublicShare(c echo.Context) error {
+	instance := middlewares.GetInstance(c)
+
+	share, err := sharings.GetPublicShare(instance, c.Param("id"))
+	if err != nil {
+		return wrapPublicShareError(err)
+	}
+
+	var params publicShareParams
+	if err := c.Bind(&params); err != nil {
+		return jsonapi.NewError(http.StatusBadRequest, err)
+	}
+
+	var password *string
+	if c.Request().Header.Get("X-Password-Set") != "" {
+		password = &params.Password
+	}
+	if err := sharings.UpdatePublicShare(instance, share, params.Description, params.ExpirationDate, password); err != nil {
+		return wrapPublicShareError(err)
+	}
+	return jsonapi.Data(c, http.StatusOK, &apiPublicShare{share}, nil)
+}
+
+// deletePublicShare handles DELETE /sharings/public/:id
+func deletePublicShare(c echo.Context) error {
+	instance := middlewares.GetInstance(c)
+
+	share, err := sharings.GetPublicShare(instance, c.Param("id"))
+	if err != nil {
+		return wrapPublicShareError(err)
+	}
+	if err := sharings.RevokePublicShare(instance, share); err != nil {
+		return wrapPublicShareError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func wrapPublicShareError(err error) error {
+	switch err {
+	case sharings.ErrPublicShareNotFound:
+		return jsonapi.NotFound(err)
+	case sharings.ErrMissingScope:
+		return jsonapi.BadRequest(err)
+	}
+	return jsonapi.InternalServerError(err)
+}
+
+// apiPublicShare wraps a sharings.PublicShare to satisfy jsonapi.Object.
+type apiPublicShare struct {
+	*sharings.PublicShare
+}
+
+// MarshalJSON builds the attributes of a public share, omitting
+// PasswordHash: whether a password is set is exposed via HasPassword,
+// never the hash itself.
+func (p *apiPublicShare) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID             string     `json:"_id,omitempty"`
+		Rev            string     `json:"_rev,omitempty"`
+		Token          string     `json:"token"`
+		Description    string     `json:"description,omitempty"`
+		HasPassword    bool       `json:"has_password"`
+		ExpirationDate *time.Time `json:"expiration_date,omitempty"`
+	}{
+		ID:             p.ID(),
+		Rev:            p.Rev(),
+		Token:          p.Token,
+		Description:    p.Description,
+		HasPassword:    p.HasPassword(),
+		ExpirationDate: p.ExpirationDate,
+	})
+}
+
+func (p *apiPublicShare) Relationships() jsonapi.RelationshipMap { return nil }
+func (p *apiPublicShare) Included() []jsonapi.Object             { return nil }
+func (p *apiPublicShare) Links() *jsonapi.LinksList {
+	return &jsonapi.LinksList{Self: "/public/" + p.Token}
+}
+
+// publicShareRoutes registers the authenticated /sharings/public
+// management routes on the given router.
+func publicShareRoutes(router *echo.Group) {
+	router.POST("/public", postPublicShare)
+	router.PATCH("/public/:id", patchPublicShare)
+	router.DELETE("/public/:id", deletePublicShare)
+}
+
+// publicTokenRoutes registers the unauthenticated /public/:token route
+// on the given router.
+func publicTokenRoutes(publicRouter *echo.Group) {
+	publicRouter.GET("/:token", getPublicShare)
+}
@@ -0,0 +1,25 @@
+package sharings
+
+import (
+	"github.com/labstack/echo"
+)
+
+// Routes sets the routing for the sharings web service, mounted under
+// /sharings by the top-level router.
+func Routes(router *echo.Group) {
+	lifecycleRoutes(router)
+	publicShareRoutes(router)
+	ocmInvitationRoutes(router)
+
+	ocmGroup := router.Group("/ocm")
+	ocmRoutes(ocmGroup)
+}
+
+// PublicRoutes sets the routing for the unauthenticated, token-based
+// public share endpoint.
+//
+// It is mounted under /public by the top-level router, outside of the
+// usual instance authentication middleware.
+func PublicRoutes(router *echo.Group) {
+	publicTokenRoutes(router)
+}